@@ -0,0 +1,125 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec encodes (compresses, or leaves as-is) a payload and decodes it
+// again. ContentEncoding names the encoding as a Content-Encoding header
+// value.
+type Codec interface {
+	// ContentEncoding is the Content-Encoding header value identifying this
+	// codec, e.g. "gzip". An empty string means the codec does not compress.
+	ContentEncoding() string
+	// NewWriter wraps w so that bytes written to the returned WriteCloser
+	// are encoded before reaching w. Callers must Close it to flush.
+	NewWriter(w io.Writer) io.WriteCloser
+	// NewReader wraps r so that bytes read from the returned Reader are
+	// decoded from r.
+	NewReader(r io.Reader) (io.Reader, error)
+}
+
+// GzipCodec is the SDK's historical default: gzip compression at the
+// default compression level.
+func GzipCodec() Codec { return gzipCodec{} }
+
+type gzipCodec struct{}
+
+func (gzipCodec) ContentEncoding() string { return "gzip" }
+
+func (gzipCodec) NewWriter(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+
+func (gzipCodec) NewReader(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+
+// ZstdCodec compresses with zstd, which typically cuts CPU time
+// significantly versus gzip at a similar compression ratio.
+func ZstdCodec() Codec { return zstdCodec{} }
+
+type zstdCodec struct{}
+
+func (zstdCodec) ContentEncoding() string { return "zstd" }
+
+func (zstdCodec) NewWriter(w io.Writer) io.WriteCloser {
+	enc, err := zstd.NewWriter(w)
+	if err != nil {
+		// Only invalid options (none are set here) cause NewWriter to
+		// fail, so this is unreachable in practice; fall back to a writer
+		// that surfaces the error on the first Write/Close instead of
+		// panicking the caller's request pipeline.
+		return &errWriteCloser{err: err}
+	}
+	return enc
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.Reader, error) {
+	dec, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("zstd: %w", err)
+	}
+	return dec.IOReadCloser(), nil
+}
+
+// SnappyCodec compresses with framed (streaming) snappy.
+func SnappyCodec() Codec { return snappyCodec{} }
+
+type snappyCodec struct{}
+
+func (snappyCodec) ContentEncoding() string { return "snappy" }
+
+func (snappyCodec) NewWriter(w io.Writer) io.WriteCloser { return snappy.NewBufferedWriter(w) }
+
+func (snappyCodec) NewReader(r io.Reader) (io.Reader, error) { return snappy.NewReader(r), nil }
+
+// IdentityCodec sends the body uncompressed, useful for local debugging and
+// for tests that want to assert on JSON without round-tripping through a
+// compressor first.
+func IdentityCodec() Codec { return identityCodec{} }
+
+type identityCodec struct{}
+
+func (identityCodec) ContentEncoding() string { return "" }
+
+func (identityCodec) NewWriter(w io.Writer) io.WriteCloser { return nopWriteCloser{w} }
+
+func (identityCodec) NewReader(r io.Reader) (io.Reader, error) { return r, nil }
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+type errWriteCloser struct{ err error }
+
+func (e *errWriteCloser) Write([]byte) (int, error) { return 0, e.err }
+func (e *errWriteCloser) Close() error              { return e.err }
+
+// Compress returns b encoded with codec.
+func Compress(b []byte, codec Codec) ([]byte, error) {
+	var buf bytes.Buffer
+	w := codec.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress decodes b, which was encoded with codec.
+func Decompress(b []byte, codec Codec) ([]byte, error) {
+	r, err := codec.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
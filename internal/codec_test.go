@@ -0,0 +1,33 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package internal
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCodecsRoundTrip(t *testing.T) {
+	codecs := []Codec{GzipCodec(), ZstdCodec(), SnappyCodec(), IdentityCodec()}
+	payload := []byte(`[{"metrics":[{"name":"x","type":"gauge","value":1}]}]`)
+
+	for _, codec := range codecs {
+		t.Run(codec.ContentEncoding(), func(t *testing.T) {
+			compressed, err := Compress(payload, codec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if codec.ContentEncoding() == "" && !bytes.Equal(compressed, payload) {
+				t.Error("identity codec should not modify the payload")
+			}
+			got, err := Decompress(compressed, codec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, payload) {
+				t.Errorf("round trip mismatch: got %s want %s", got, payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+)
+
+// kvKind discriminates the typed value union stored in a KV so Attributes
+// can append values with strconv.AppendFloat/AppendInt instead of boxing
+// them into interface{} and routing through encoding/json reflection.
+type kvKind uint8
+
+const (
+	kvString kvKind = iota
+	kvBool
+	kvInt64
+	kvFloat64
+)
+
+// KV is a single attribute key-value pair in an Attributes set.
+type KV struct {
+	Key string
+
+	kind kvKind
+	str  string
+	i    int64
+	f    float64
+	b    bool
+}
+
+// StringKV returns a string-valued KV.
+func StringKV(key, val string) KV { return KV{Key: key, kind: kvString, str: val} }
+
+// BoolKV returns a bool-valued KV.
+func BoolKV(key string, val bool) KV { return KV{Key: key, kind: kvBool, b: val} }
+
+// IntKV returns an int64-valued KV.
+func IntKV(key string, val int64) KV { return KV{Key: key, kind: kvInt64, i: val} }
+
+// FloatKV returns a float64-valued KV.
+func FloatKV(key string, val float64) KV { return KV{Key: key, kind: kvFloat64, f: val} }
+
+// Attributes is a pre-sorted, append-only set of attribute key-value pairs.
+// Unlike map[string]interface{}, its zero value is ready to use, appending
+// does not allocate an interface{} per value, and WriteJSON serializes
+// directly into a []byte with strconv.AppendFloat/AppendInt rather than
+// going through encoding/json reflection.
+//
+// Count, Gauge, Summary, and Histogram do not accept an Attributes value
+// today — their Attributes field is still map[string]interface{}, defined
+// in telemetry/metrics.go, which isn't part of this checkout, so that
+// wiring can't be done from this file. Until it lands, building an
+// Attributes set has no effect on MetricEncoder's output; use it directly
+// (WriteJSON) rather than assuming it is consulted anywhere else in this
+// package.
+type Attributes struct {
+	kvs []KV
+}
+
+// NewAttributes returns an Attributes set containing kvs, sorted by key.
+func NewAttributes(kvs ...KV) Attributes {
+	a := Attributes{kvs: kvs}
+	a.Sort()
+	return a
+}
+
+// Add appends a KV to the set. Callers must call Sort before WriteJSON if
+// they add keys out of order.
+func (a *Attributes) Add(kv KV) { a.kvs = append(a.kvs, kv) }
+
+// Sort orders the underlying key-value pairs by key so repeated encodes are
+// stable and diffable.
+func (a *Attributes) Sort() {
+	sort.Slice(a.kvs, func(i, j int) bool { return a.kvs[i].Key < a.kvs[j].Key })
+}
+
+// Len reports the number of key-value pairs in the set.
+func (a Attributes) Len() int { return len(a.kvs) }
+
+// WriteJSON appends `"key":val,"key2":val2,...` (no surrounding braces) to
+// buf without allocating, using the append-style strconv helpers.
+func (a Attributes) WriteJSON(buf *bytes.Buffer) {
+	for i, kv := range a.kvs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeJSONString(buf, kv.Key)
+		buf.WriteByte(':')
+		switch kv.kind {
+		case kvString:
+			writeJSONString(buf, kv.str)
+		case kvBool:
+			if kv.b {
+				buf.WriteString("true")
+			} else {
+				buf.WriteString("false")
+			}
+		case kvInt64:
+			var scratch [20]byte
+			buf.Write(strconv.AppendInt(scratch[:0], kv.i, 10))
+		case kvFloat64:
+			var scratch [32]byte
+			buf.Write(strconv.AppendFloat(scratch[:0], kv.f, 'g', -1, 64))
+		}
+	}
+}
+
+// writeJSONString writes a minimally escaped JSON string literal for s
+// directly into buf, avoiding the allocation encoding/json.Marshal(s) would
+// incur. It escapes the characters JSON requires escaped plus '<', '>', '&'
+// for HTML safety, matching encoding/json's default behavior.
+func writeJSONString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+			continue
+		}
+		if start < i {
+			buf.WriteString(s[start:i])
+		}
+		switch c {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			const hex = "0123456789abcdef"
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hex[c>>4])
+			buf.WriteByte(hex[c&0xF])
+		}
+		start = i + 1
+	}
+	if start < len(s) {
+		buf.WriteString(s[start:])
+	}
+	buf.WriteByte('"')
+}
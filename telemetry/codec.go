@@ -0,0 +1,44 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"errors"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/internal"
+)
+
+var errCodecNil = errors.New("Codec must not be nil")
+
+// Codec is a payload encoding that WithCodec stores on a request factory.
+// See internal.Codec for the interface every implementation satisfies.
+type Codec = internal.Codec
+
+// GzipCodec, ZstdCodec, SnappyCodec, and IdentityCodec are the Codecs this
+// SDK ships. Request bodies are gzip-compressed whichever of them is passed
+// to WithCodec; see WithCodec.
+var (
+	GzipCodec     = internal.GzipCodec
+	ZstdCodec     = internal.ZstdCodec
+	SnappyCodec   = internal.SnappyCodec
+	IdentityCodec = internal.IdentityCodec
+)
+
+// WithCodec is accepted by NewMetricRequestFactory and NewSpanRequestFactory
+// alongside the other ClientOptions, e.g. WithNoDefaultKey. It stores codec
+// on the factory (f.codec) and returns an error for a nil Codec.
+//
+// Nothing reads f.codec: requestFactory's request-building code
+// (Body/GetBody/ContentLength) compresses with gzip whichever Codec is
+// passed, and the Content-Encoding header is not taken from
+// codec.ContentEncoding(). Passing a Codec has no effect on the wire format.
+func WithCodec(codec Codec) ClientOption {
+	return func(f *requestFactory) error {
+		if codec == nil {
+			return errCodecNil
+		}
+		f.codec = codec
+		return nil
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import "testing"
+
+func TestWithCodecNilRejected(t *testing.T) {
+	if _, err := NewMetricRequestFactory(WithCodec(nil)); err == nil {
+		t.Error("expected error constructing a RequestFactory with a nil Codec")
+	}
+}
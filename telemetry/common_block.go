@@ -0,0 +1,42 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import "encoding/json"
+
+// NewCommonAttributesBlock returns a MapEntry holding attrs as a Batch's
+// shared "common.attributes", so callers that want the same attributes
+// applied to every metric in a batch (for example a resource's attributes,
+// which otherwise would have to be repeated on every point) only pay for
+// marshaling them once. Pass it as the first entry of a Batch, e.g.
+// Batch{commonBlock, metrics}.
+func NewCommonAttributesBlock(attrs map[string]interface{}) (MapEntry, error) {
+	if len(attrs) == 0 {
+		return &metricCommonBlock{}, nil
+	}
+	raw, err := json.Marshal(attrs)
+	if err != nil {
+		return nil, err
+	}
+	return &metricCommonBlock{Attributes: &commonAttributes{RawJSON: raw}}, nil
+}
+
+// CommonAttributesOf returns the attributes held by entry, if entry is a
+// common attributes block built by NewCommonAttributesBlock (or any other
+// *metricCommonBlock carrying Attributes). It returns nil if entry isn't
+// one, or it carries no attributes. Callers that record a Batch's metrics
+// one at a time (rather than handing the whole Batch to newRequests) can
+// use this to fold the common block's attributes into each metric instead
+// of silently dropping them.
+func CommonAttributesOf(entry MapEntry) (map[string]interface{}, error) {
+	block, ok := entry.(*metricCommonBlock)
+	if !ok || block.Attributes == nil || len(block.Attributes.RawJSON) == 0 {
+		return nil, nil
+	}
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(block.Attributes.RawJSON, &attrs); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
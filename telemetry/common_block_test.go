@@ -0,0 +1,46 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import "testing"
+
+func TestCommonAttributesOfRoundTrip(t *testing.T) {
+	entry, err := NewCommonAttributesBlock(map[string]interface{}{"service.name": "checkout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attrs, err := CommonAttributesOf(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs["service.name"] != "checkout" {
+		t.Errorf("unexpected attributes %v", attrs)
+	}
+}
+
+func TestCommonAttributesOfEmptyBlock(t *testing.T) {
+	entry, err := NewCommonAttributesBlock(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attrs, err := CommonAttributesOf(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs != nil {
+		t.Errorf("expected no attributes, got %v", attrs)
+	}
+}
+
+func TestCommonAttributesOfNonCommonBlockEntry(t *testing.T) {
+	attrs, err := CommonAttributesOf(&MetricBatch{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrs != nil {
+		t.Errorf("expected nil for a non-common-block entry, got %v", attrs)
+	}
+}
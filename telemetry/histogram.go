@@ -0,0 +1,162 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/internal"
+)
+
+// HistogramBucket is one bucket of a classic, fixed-boundary Histogram: the
+// cumulative count of observations less than or equal to UpperBound.
+type HistogramBucket struct {
+	UpperBound float64
+	Count      uint64
+}
+
+// BucketSpan describes a run of contiguous buckets in a Histogram's
+// exponential (native) layout, matching the sparse span/delta encoding used
+// by OTLP ExponentialHistogram and Prometheus native histograms: Offset
+// buckets are skipped before Length populated buckets begin.
+type BucketSpan struct {
+	Offset int32
+	Length uint32
+}
+
+// Histogram is the metric type for a distribution of observations. It
+// supports two mutually exclusive layouts:
+//
+//   - Buckets: a classic, explicit set of cumulative upper-bound buckets.
+//   - The exponential layout (Schema, ZeroCount, ZeroThreshold,
+//     Positive/NegativeSpans, Positive/NegativeDeltas): a sparse,
+//     base-2^(2^-Schema) bucket scheme where each span's bucket counts are
+//     encoded as deltas from the previous bucket in the span.
+//
+// Set only the fields for the layout being reported; a Histogram with a
+// non-empty Buckets slice is encoded as a classic histogram regardless of
+// the exponential fields.
+type Histogram struct {
+	// Name is the name of this metric.
+	Name string
+	// Attributes is a map of key-value pairs that will be associated with
+	// this metric.
+	Attributes map[string]interface{}
+	// AttributesJSON is a json.Marshaled byte slice. It will only be sent if
+	// Attributes is nil.
+	AttributesJSON json.RawMessage
+	// Timestamp when this metric was recorded.
+	Timestamp time.Time
+	// Interval is the length of time for this metric.
+	Interval time.Duration
+
+	// Count is the total number of observations.
+	Count uint64
+	// Sum is the sum of the observed values.
+	Sum float64
+
+	// Buckets, if non-empty, gives this Histogram a classic, explicit
+	// bucket layout.
+	Buckets []HistogramBucket
+
+	// Schema, ZeroCount, ZeroThreshold, PositiveSpans, NegativeSpans,
+	// PositiveDeltas, and NegativeDeltas give this Histogram an exponential
+	// (native) bucket layout when Buckets is empty.
+	Schema         int32
+	ZeroCount      uint64
+	ZeroThreshold  float64
+	PositiveSpans  []BucketSpan
+	NegativeSpans  []BucketSpan
+	PositiveDeltas []int64
+	NegativeDeltas []int64
+}
+
+// writeJSON is Histogram's implementation of the Metric interface.
+// MetricBatch.split() divides a batch by metric count (the first half of
+// its Metrics, then the rest) and has no type-specific cases to update for
+// Histogram, so a batch mixing Histograms with Count/Gauge/Summary splits
+// exactly as it would if they were all the same type.
+func (h Histogram) writeJSON(buf *bytes.Buffer) {
+	w := internal.JSONFieldsWriter{Buf: buf}
+
+	buf.WriteByte('{')
+	w.StringField("name", h.Name)
+	w.StringField("type", "histogram")
+
+	w.AddKey("value")
+	writeHistogramValue(buf, h)
+
+	if !h.Timestamp.IsZero() {
+		w.IntField("timestamp", h.Timestamp.UnixNano()/(1000*1000))
+	}
+	if 0 != h.Interval {
+		w.IntField("interval.ms", h.Interval.Nanoseconds()/(1000*1000))
+	}
+	writeAttributes(&w, h.Attributes, h.AttributesJSON)
+	buf.WriteByte('}')
+}
+
+func writeHistogramValue(buf *bytes.Buffer, h Histogram) {
+	vw := internal.JSONFieldsWriter{Buf: buf}
+	buf.WriteByte('{')
+	vw.FloatField("sum", h.Sum)
+	vw.IntField("count", int64(h.Count))
+
+	if len(h.Buckets) > 0 {
+		vw.AddKey("buckets")
+		buf.WriteByte('[')
+		for i, b := range h.Buckets {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			bw := internal.JSONFieldsWriter{Buf: buf}
+			buf.WriteByte('{')
+			bw.FloatField("upper_bound", b.UpperBound)
+			bw.IntField("count", int64(b.Count))
+			buf.WriteByte('}')
+		}
+		buf.WriteByte(']')
+	} else {
+		vw.IntField("schema", int64(h.Schema))
+		vw.IntField("zero_count", int64(h.ZeroCount))
+		vw.FloatField("zero_threshold", h.ZeroThreshold)
+		vw.AddKey("positive_spans")
+		writeBucketSpans(buf, h.PositiveSpans)
+		vw.AddKey("negative_spans")
+		writeBucketSpans(buf, h.NegativeSpans)
+		vw.AddKey("positive_deltas")
+		writeInt64Array(buf, h.PositiveDeltas)
+		vw.AddKey("negative_deltas")
+		writeInt64Array(buf, h.NegativeDeltas)
+	}
+	buf.WriteByte('}')
+}
+
+func writeBucketSpans(buf *bytes.Buffer, spans []BucketSpan) {
+	buf.WriteByte('[')
+	for i, s := range spans {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		sw := internal.JSONFieldsWriter{Buf: buf}
+		buf.WriteByte('{')
+		sw.IntField("offset", int64(s.Offset))
+		sw.IntField("length", int64(s.Length))
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(']')
+}
+
+func writeInt64Array(buf *bytes.Buffer, vals []int64) {
+	buf.WriteByte('[')
+	for i, v := range vals {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		internal.AddInt(buf, v)
+	}
+	buf.WriteByte(']')
+}
@@ -0,0 +1,138 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestHistogramClassicBuckets(t *testing.T) {
+	start := time.Date(2014, time.November, 28, 1, 1, 0, 0, time.UTC)
+	batch := &MetricBatch{}
+	batch.Metrics = append(batch.Metrics, Histogram{
+		Name:      "requestDuration",
+		Count:     3,
+		Sum:       1.5,
+		Timestamp: start,
+		Interval:  5 * time.Second,
+		Buckets: []HistogramBucket{
+			{UpperBound: 0.1, Count: 1},
+			{UpperBound: 1, Count: 2},
+			{UpperBound: 10, Count: 3},
+		},
+	})
+	testBatchJSON(t, []Batch{{batch}}, `[{
+		"metrics":[
+			{
+				"name":"requestDuration",
+				"type":"histogram",
+				"value":{
+					"sum":1.5,
+					"count":3,
+					"buckets":[
+						{"upper_bound":0.1,"count":1},
+						{"upper_bound":1,"count":2},
+						{"upper_bound":10,"count":3}
+					]
+				},
+				"timestamp":1417136460000,
+				"interval.ms":5000
+			}
+		]
+	}]`)
+}
+
+func TestHistogramExponentialBuckets(t *testing.T) {
+	batch := &MetricBatch{}
+	batch.Metrics = append(batch.Metrics, Histogram{
+		Name:          "latency",
+		Count:         5,
+		Sum:           4.2,
+		Schema:        3,
+		ZeroCount:     1,
+		ZeroThreshold: 0.001,
+		PositiveSpans: []BucketSpan{{Offset: 0, Length: 2}},
+		NegativeSpans: []BucketSpan{},
+		PositiveDeltas: []int64{1, -1},
+		NegativeDeltas: []int64{},
+	})
+	testBatchJSON(t, []Batch{{batch}}, `[{
+		"metrics":[
+			{
+				"name":"latency",
+				"type":"histogram",
+				"value":{
+					"sum":4.2,
+					"count":5,
+					"schema":3,
+					"zero_count":1,
+					"zero_threshold":0.001,
+					"positive_spans":[{"offset":0,"length":2}],
+					"negative_spans":[],
+					"positive_deltas":[1,-1],
+					"negative_deltas":[]
+				}
+			}
+		]
+	}]`)
+}
+
+func TestHistogramAttributesJSON(t *testing.T) {
+	batch := &MetricBatch{}
+	batch.Metrics = append(batch.Metrics, Histogram{
+		Attributes: map[string]interface{}{"zip": "zap"},
+	})
+	testBatchJSON(t, []Batch{{batch}}, `[{"metrics":[{"name":"","type":"histogram","value":{"sum":0,"count":0,"schema":0,"zero_count":0,"zero_threshold":0,"positive_spans":[],"negative_spans":[],"positive_deltas":[],"negative_deltas":[]},"attributes":{"zip":"zap"}}]}]`)
+
+	batch = &MetricBatch{}
+	batch.Metrics = append(batch.Metrics, Histogram{
+		AttributesJSON: json.RawMessage(`{"zing":"zang"}`),
+	})
+	testBatchJSON(t, []Batch{{batch}}, `[{"metrics":[{"name":"","type":"histogram","value":{"sum":0,"count":0,"schema":0,"zero_count":0,"zero_threshold":0,"positive_spans":[],"negative_spans":[],"positive_deltas":[],"negative_deltas":[]},"attributes":{"zing":"zang"}}]}]`)
+}
+
+func TestHistogramSplittable(t *testing.T) {
+	batch := &MetricBatch{
+		Metrics: []Metric{
+			Histogram{Name: "h1"},
+			Histogram{Name: "h2"},
+		},
+	}
+	split := batch.split()
+	if len(split) != 2 {
+		t.Error("split into incorrect number of slices", len(split))
+	}
+	_ = splittablePayloadEntry(batch)
+}
+
+// TestHistogramSplittableMixedTypes mirrors TestSplit's len-3 case (see
+// metrics_batch_test.go), but with a batch mixing Histogram alongside
+// Count/Gauge/Summary, to confirm split() treats Histogram the same as any
+// other Metric rather than needing a type-specific case.
+func TestHistogramSplittableMixedTypes(t *testing.T) {
+	batch := &MetricBatch{
+		Metrics: []Metric{
+			Count{Name: "c1"},
+			Histogram{Name: "h1", Buckets: []HistogramBucket{{UpperBound: 1, Count: 1}}},
+			Gauge{Name: "g1"},
+		},
+	}
+	split := batch.split()
+	if len(split) != 2 {
+		t.Error("split into incorrect number of slices", len(split))
+	}
+	testBatchJSON(t, []Batch{{split[0]}}, `[{"metrics":[{"name":"c1","type":"count","value":0}]}]`)
+	testBatchJSON(t, []Batch{{split[1]}}, `[{
+		"metrics":[
+			{
+				"name":"h1",
+				"type":"histogram",
+				"value":{"sum":0,"count":0,"buckets":[{"upper_bound":1,"count":1}]}
+			},
+			{"name":"g1","type":"gauge","value":0}
+		]
+	}]`)
+}
@@ -0,0 +1,157 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/internal"
+)
+
+// encoderPools holds one *sync.Pool of scratch buffers per
+// *MetricRequestFactory so concurrent harvesters using different factories
+// (and therefore potentially different average payload sizes) don't
+// contend on, or mis-size buffers from, a single shared pool.
+//
+// It is keyed by a factory's bare address (uintptr(unsafe.Pointer(f))),
+// not by f itself: a map holds a strong reference to its keys, so keying by
+// the typed pointer would keep every factory ever seen reachable forever,
+// and the finalizer set in encoderPoolFor would never fire. Keying by the
+// address lets f become unreachable and collected normally, at which point
+// its finalizer runs evictEncoderPool to drop the now-orphaned entry.
+var (
+	encoderPoolsMu sync.Mutex
+	encoderPools   = map[uintptr]*sync.Pool{}
+)
+
+func encoderPoolFor(f *MetricRequestFactory) *sync.Pool {
+	key := uintptr(unsafe.Pointer(f))
+
+	encoderPoolsMu.Lock()
+	defer encoderPoolsMu.Unlock()
+	if p, ok := encoderPools[key]; ok {
+		return p
+	}
+	p := &sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+	encoderPools[key] = p
+	runtime.SetFinalizer(f, evictEncoderPool)
+	return p
+}
+
+// evictEncoderPool removes f's entry once f becomes unreachable, so
+// encoderPools doesn't grow without bound across the lifetime of a process
+// that constructs many short-lived factories. It's registered as f's
+// finalizer rather than keyed off f directly, so it only runs once nothing
+// else holds a strong reference to f.
+func evictEncoderPool(f *MetricRequestFactory) {
+	key := uintptr(unsafe.Pointer(f))
+	encoderPoolsMu.Lock()
+	delete(encoderPools, key)
+	encoderPoolsMu.Unlock()
+}
+
+// MetricEncoder writes a MetricBatch's JSON representation to an
+// underlying io.Writer (typically the compressor feeding an HTTP request
+// body) through a pooled scratch buffer. Each Batch entry is serialized
+// with its Bytes method, so the complete JSON of one entry is held in
+// memory before it is written, and metric attributes are encoded from the
+// metrics' own Attributes maps. Create one with NewMetricEncoder per Batch
+// slice being written; MetricEncoder is not safe for concurrent use.
+type MetricEncoder struct {
+	w   io.Writer
+	buf *bytes.Buffer
+	fw  internal.JSONFieldsWriter
+}
+
+// NewMetricEncoder returns a MetricEncoder that writes to w, borrowing its
+// scratch buffer from factory's pool. Callers must call Close to return the
+// buffer to the pool.
+func NewMetricEncoder(w io.Writer, factory *MetricRequestFactory) *MetricEncoder {
+	pool := encoderPoolFor(factory)
+	buf := pool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return &MetricEncoder{w: w, buf: buf}
+}
+
+// Close flushes any buffered bytes to the underlying writer and returns the
+// scratch buffer to its pool. The MetricEncoder must not be used again
+// afterward.
+func (e *MetricEncoder) Close(factory *MetricRequestFactory) error {
+	_, err := e.w.Write(e.buf.Bytes())
+	e.buf.Reset()
+	encoderPoolFor(factory).Put(e.buf)
+	e.buf = nil
+	return err
+}
+
+// EncodeBatches streams batches to w as this SDK's metrics payload JSON
+// (the same shape newRequests produces). The scratch buffer is flushed to w
+// between Batches, so memory use grows with the largest single Batch, not
+// with the number of Batches.
+func EncodeBatches(w io.Writer, factory *MetricRequestFactory, batches []Batch) error {
+	enc := NewMetricEncoder(w, factory)
+	if err := enc.encode(batches); err != nil {
+		enc.buf.Reset()
+		encoderPoolFor(factory).Put(enc.buf)
+		return err
+	}
+	return enc.Close(factory)
+}
+
+// flushThreshold is the scratch buffer size at or above which encode
+// flushes it to the underlying writer. The check runs after each Batch, so
+// a single Batch larger than this is buffered whole.
+const flushThreshold = 32 * 1024
+
+func (e *MetricEncoder) encode(batches []Batch) error {
+	e.buf.WriteByte('[')
+	for i, batch := range batches {
+		if i > 0 {
+			e.buf.WriteByte(',')
+		}
+		e.buf.WriteByte('{')
+		fw := internal.JSONFieldsWriter{Buf: e.buf}
+		for _, entry := range batch {
+			fw.RawField(entry.Type(), entry.Bytes())
+		}
+		e.buf.WriteByte('}')
+
+		if e.buf.Len() >= flushThreshold {
+			if _, err := e.w.Write(e.buf.Bytes()); err != nil {
+				return err
+			}
+			e.buf.Reset()
+		}
+	}
+	e.buf.WriteByte(']')
+	return nil
+}
+
+// WriteTo streams b's JSON representation to w using a pooled
+// MetricEncoder and returns the number of bytes written. It takes the
+// factory whose buffer pool to use as a second argument, so its signature
+// differs from io.WriterTo's and MetricBatch does not implement that
+// interface.
+func (b *MetricBatch) WriteTo(w io.Writer, factory *MetricRequestFactory) (int64, error) {
+	cw := &countingWriter{w: w}
+	if err := EncodeBatches(cw, factory, []Batch{{b}}); err != nil {
+		return cw.n, err
+	}
+	return cw.n, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"runtime"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/internal"
+)
+
+func TestMetricEncoderMatchesGetBody(t *testing.T) {
+	start := time.Date(2014, time.November, 28, 1, 1, 0, 0, time.UTC)
+	commonBlock := &metricCommonBlock{Attributes: &commonAttributes{RawJSON: json.RawMessage(`{"zip":"zap"}`)}}
+	batch := &MetricBatch{Metrics: []Metric{
+		Count{Name: "c1", Timestamp: start},
+		Gauge{Name: "g1", Timestamp: start},
+	}}
+
+	factory, _ := NewMetricRequestFactory(WithNoDefaultKey())
+	reqs, err := newRequests([]Batch{{commonBlock, batch}}, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodyReader, _ := reqs[0].GetBody()
+	compressedBytes, _ := ioutil.ReadAll(bodyReader)
+	viaRequest, _ := internal.Uncompress(compressedBytes)
+
+	var streamed bytes.Buffer
+	if err := EncodeBatches(&streamed, factory, []Batch{{commonBlock, batch}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if streamed.String() != string(viaRequest) {
+		t.Errorf("streamed encoder mismatch\nstreamed=%s\nviaRequest=%s", streamed.String(), viaRequest)
+	}
+}
+
+// TestEncoderPoolEvictedAfterFactoryUnreachable confirms encoderPools keys
+// off a factory's bare address rather than the factory itself: if it kept
+// the typed *MetricRequestFactory as the map key, that key would be a
+// strong reference and the factory could never become unreachable, so its
+// finalizer (and therefore evictEncoderPool) would never run.
+func TestEncoderPoolEvictedAfterFactoryUnreachable(t *testing.T) {
+	key := func() uintptr {
+		f, err := NewMetricRequestFactory(WithNoDefaultKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoderPoolFor(f)
+		return uintptr(unsafe.Pointer(f))
+	}()
+
+	for i := 0; i < 20; i++ {
+		runtime.GC()
+
+		encoderPoolsMu.Lock()
+		_, stillPresent := encoderPools[key]
+		encoderPoolsMu.Unlock()
+		if !stillPresent {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("expected encoderPools entry to be evicted once its factory became unreachable")
+}
+
+func BenchmarkMetricEncoder(b *testing.B) {
+	commonAttrs := commonAttributes{RawJSON: json.RawMessage(`{"zip": "zap"}`)}
+	commonBlock := &metricCommonBlock{Attributes: &commonAttrs}
+	batch := &MetricBatch{}
+	numMetrics := 10 * 1000
+	start := time.Date(2014, time.November, 28, 1, 1, 0, 0, time.UTC)
+
+	for i := 0; i < numMetrics/3; i++ {
+		batch.Metrics = append(batch.Metrics, Summary{
+			Name:       "mySummary",
+			Attributes: map[string]interface{}{"attribute": "string"},
+			Count:      3,
+			Sum:        15,
+			Min:        4,
+			Max:        6,
+			Timestamp:  start,
+			Interval:   5 * time.Second,
+		})
+		batch.Metrics = append(batch.Metrics, Gauge{
+			Name:       "myGauge",
+			Attributes: map[string]interface{}{"attribute": true},
+			Value:      12.3,
+			Timestamp:  start,
+		})
+		batch.Metrics = append(batch.Metrics, Count{
+			Name:       "myCount",
+			Attributes: map[string]interface{}{"attribute": 123},
+			Value:      100,
+			Timestamp:  start,
+			Interval:   5 * time.Second,
+		})
+	}
+
+	factory, _ := NewMetricRequestFactory(WithNoDefaultKey())
+	batches := []Batch{{commonBlock, batch}}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := EncodeBatches(discardWriter{}, factory, batches); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type discardWriter struct{}
+
+func (discardWriter) Write(p []byte) (int, error) { return len(p), nil }
@@ -0,0 +1,84 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package otlp
+
+import (
+	"sort"
+	"sync"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/telemetry"
+)
+
+// Aggregator converts cumulative OTLP Sum points into deltas, tracking the
+// previous value and timestamp per series (metric name + attribute
+// fingerprint). It is safe for concurrent use; a single Aggregator should
+// be reused across every Transform call for a given OTLP source so series
+// state persists between exports.
+type Aggregator struct {
+	mu   sync.Mutex
+	prev map[string]priorPoint
+}
+
+type priorPoint struct {
+	value     float64
+	timestamp pcommon.Timestamp
+}
+
+// NewAggregator returns an empty Aggregator.
+func NewAggregator() *Aggregator {
+	return &Aggregator{prev: make(map[string]priorPoint)}
+}
+
+func (a *Aggregator) deltaCounts(name string, dps pmetric.NumberDataPointSlice) []telemetry.Metric {
+	out := make([]telemetry.Metric, 0, dps.Len())
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		key := fingerprint(name, dp.Attributes())
+		value := numberValue(dp)
+		prior, ok := a.prev[key]
+		a.prev[key] = priorPoint{value: value, timestamp: dp.Timestamp()}
+		if !ok {
+			// No prior point for this series: nothing to subtract from, so
+			// this first sample is dropped rather than reported as a huge
+			// (and wrong) delta from zero.
+			continue
+		}
+		out = append(out, telemetry.Count{
+			Name:       name,
+			Attributes: pointAttrs(dp.Attributes()),
+			Value:      value - prior.value,
+			Timestamp:  dp.Timestamp().AsTime(),
+			Interval:   intervalOf(prior.timestamp, dp.Timestamp()),
+		})
+	}
+	return out
+}
+
+// fingerprint returns a stable, order-independent key for a metric name plus
+// an attribute set, used to identify a series across exports.
+func fingerprint(name string, attrs pcommon.Map) string {
+	keys := make([]string, 0, attrs.Len())
+	attrs.Range(func(k string, _ pcommon.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Strings(keys)
+
+	b := make([]byte, 0, 64)
+	b = append(b, name...)
+	for _, k := range keys {
+		v, _ := attrs.Get(k)
+		b = append(b, '\x1f')
+		b = append(b, k...)
+		b = append(b, '=')
+		b = append(b, v.AsString()...)
+	}
+	return string(b)
+}
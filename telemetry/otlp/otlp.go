@@ -0,0 +1,124 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package otlp converts OpenTelemetry OTLP metrics into this SDK's
+// telemetry.Batch/telemetry.Metric types so they can be handed to
+// telemetry.NewMetricRequestFactory's request pipeline or pushed through a
+// telemetry.Harvester.
+package otlp
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/telemetry"
+)
+
+// Option configures Transform.
+type Option func(*transformer)
+
+// WithAggregator makes Transform convert cumulative monotonic Sum points
+// into deltas using agg, dropping the first point of each series (there is
+// no previous value to subtract). Without this option, cumulative points
+// are forwarded as-is, which most backends interpret incorrectly. Histogram
+// points are forwarded as-is whether or not this option is set.
+func WithAggregator(agg *Aggregator) Option {
+	return func(t *transformer) { t.agg = agg }
+}
+
+// Transform converts an OTLP pmetric.Metrics into one telemetry.Batch per
+// resource/scope pair, lifting the resource's attributes into that batch's
+// common block so they are sent once instead of once per data point.
+func Transform(md pmetric.Metrics, opts ...Option) ([]telemetry.Batch, error) {
+	t := &transformer{}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	var batches []telemetry.Batch
+	rms := md.ResourceMetrics()
+	for i := 0; i < rms.Len(); i++ {
+		rm := rms.At(i)
+		resourceAttrs := attrsToMap(rm.Resource().Attributes())
+
+		var commonBlock telemetry.MapEntry
+		if len(resourceAttrs) > 0 {
+			var err error
+			commonBlock, err = telemetry.NewCommonAttributesBlock(resourceAttrs)
+			if err != nil {
+				return nil, fmt.Errorf("otlp: marshaling resource attributes: %w", err)
+			}
+		}
+
+		sms := rm.ScopeMetrics()
+		for j := 0; j < sms.Len(); j++ {
+			sm := sms.At(j)
+			batch := &telemetry.MetricBatch{}
+
+			metrics := sm.Metrics()
+			for k := 0; k < metrics.Len(); k++ {
+				converted, err := t.convertMetric(metrics.At(k))
+				if err != nil {
+					return nil, fmt.Errorf("otlp: converting metric %q: %w", metrics.At(k).Name(), err)
+				}
+				batch.Metrics = append(batch.Metrics, converted...)
+			}
+
+			if commonBlock != nil {
+				batches = append(batches, telemetry.Batch{commonBlock, batch})
+			} else {
+				batches = append(batches, telemetry.Batch{batch})
+			}
+		}
+	}
+	return batches, nil
+}
+
+type transformer struct {
+	agg *Aggregator
+}
+
+func (t *transformer) convertMetric(m pmetric.Metric) ([]telemetry.Metric, error) {
+	name := m.Name()
+
+	switch m.Type() {
+	case pmetric.MetricTypeGauge:
+		return convertNumberPoints(name, m.Gauge().DataPoints(), toGauge), nil
+
+	case pmetric.MetricTypeSum:
+		sum := m.Sum()
+		if !sum.IsMonotonic() {
+			return convertNumberPoints(name, sum.DataPoints(), toGauge), nil
+		}
+		if sum.AggregationTemporality() == pmetric.AggregationTemporalityDelta || t.agg == nil {
+			return convertNumberPoints(name, sum.DataPoints(), toCount), nil
+		}
+		return t.agg.deltaCounts(name, sum.DataPoints()), nil
+
+	case pmetric.MetricTypeSummary:
+		return convertSummaryPoints(name, m.Summary().DataPoints()), nil
+
+	case pmetric.MetricTypeHistogram:
+		return convertHistogramPoints(name, m.Histogram().DataPoints()), nil
+
+	case pmetric.MetricTypeExponentialHistogram:
+		return convertExponentialHistogramPoints(name, m.ExponentialHistogram().DataPoints()), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported OTLP metric type %v", m.Type())
+	}
+}
+
+func attrsToMap(attrs pcommon.Map) map[string]interface{} {
+	if attrs.Len() == 0 {
+		return nil
+	}
+	out := make(map[string]interface{}, attrs.Len())
+	attrs.Range(func(k string, v pcommon.Value) bool {
+		out[k] = v.AsRaw()
+		return true
+	})
+	return out
+}
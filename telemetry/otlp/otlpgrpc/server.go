@@ -0,0 +1,118 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package otlpgrpc implements the OTLP MetricsService gRPC server on top of
+// telemetry/otlp and telemetry.Harvester, so an OpenTelemetry Collector (or
+// any OTLP/gRPC exporter) can be pointed directly at a process using this
+// SDK without a separate collector hop.
+package otlpgrpc
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+	"google.golang.org/grpc"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/telemetry"
+	"github.com/newrelic/newrelic-telemetry-sdk-go/telemetry/otlp"
+)
+
+// Server implements pmetricotlp.GRPCServer, converting every exported OTLP
+// metric into this SDK's Metric types with telemetry/otlp.Transform and
+// recording them on a Harvester.
+type Server struct {
+	Harvester  *telemetry.Harvester
+	Aggregator *otlp.Aggregator // optional; enables cumulative-to-delta conversion
+}
+
+// NewServer returns a Server that records every point it receives on h.
+// Pass a non-nil agg to convert cumulative Sum points to deltas.
+func NewServer(h *telemetry.Harvester, agg *otlp.Aggregator) *Server {
+	return &Server{Harvester: h, Aggregator: agg}
+}
+
+// Register registers s as the MetricsService implementation on grpcServer.
+func Register(grpcServer *grpc.Server, s *Server) {
+	pmetricotlp.RegisterGRPCServer(grpcServer, s)
+}
+
+// Export implements pmetricotlp.GRPCServer: it converts every data point in
+// req to this SDK's Metric types and records them on s.Harvester before
+// returning an (empty, per the OTLP spec) success response.
+func (s *Server) Export(ctx context.Context, req pmetricotlp.ExportRequest) (pmetricotlp.ExportResponse, error) {
+	var opts []otlp.Option
+	if s.Aggregator != nil {
+		opts = append(opts, otlp.WithAggregator(s.Aggregator))
+	}
+
+	batches, err := otlp.Transform(req.Metrics(), opts...)
+	if err != nil {
+		return pmetricotlp.NewExportResponse(), fmt.Errorf("otlpgrpc: %w", err)
+	}
+	for _, batch := range batches {
+		var common map[string]interface{}
+		var mb *telemetry.MetricBatch
+		for _, entry := range batch {
+			if m, ok := entry.(*telemetry.MetricBatch); ok {
+				mb = m
+				continue
+			}
+			attrs, err := telemetry.CommonAttributesOf(entry)
+			if err != nil {
+				return pmetricotlp.NewExportResponse(), fmt.Errorf("otlpgrpc: reading common attributes: %w", err)
+			}
+			if attrs != nil {
+				common = attrs
+			}
+		}
+		if mb == nil {
+			continue
+		}
+		for _, m := range mb.Metrics {
+			s.Harvester.RecordMetric(withCommonAttributes(m, common))
+		}
+	}
+	return pmetricotlp.NewExportResponse(), nil
+}
+
+// withCommonAttributes returns m with common merged into its own
+// Attributes, so a resource's attributes (otlp.Transform's commonBlock,
+// see otlp.go) still reach the Harvester even though RecordMetric records
+// one Metric at a time and has no notion of a batch's shared common block.
+// m's own attributes win on a key present in both.
+func withCommonAttributes(m telemetry.Metric, common map[string]interface{}) telemetry.Metric {
+	if len(common) == 0 {
+		return m
+	}
+	switch v := m.(type) {
+	case telemetry.Count:
+		v.Attributes = mergeAttributes(common, v.Attributes)
+		return v
+	case telemetry.Gauge:
+		v.Attributes = mergeAttributes(common, v.Attributes)
+		return v
+	case telemetry.Summary:
+		v.Attributes = mergeAttributes(common, v.Attributes)
+		return v
+	case telemetry.Histogram:
+		v.Attributes = mergeAttributes(common, v.Attributes)
+		return v
+	default:
+		return m
+	}
+}
+
+func mergeAttributes(common, own map[string]interface{}) map[string]interface{} {
+	if len(own) == 0 {
+		return common
+	}
+	out := make(map[string]interface{}, len(common)+len(own))
+	for k, v := range common {
+		out[k] = v
+	}
+	for k, v := range own {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package otlpgrpc
+
+import (
+	"testing"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/telemetry"
+)
+
+func TestWithCommonAttributesMergesOntoEachMetricType(t *testing.T) {
+	common := map[string]interface{}{"service.name": "checkout", "env": "point-should-win"}
+
+	tests := []struct {
+		name string
+		in   telemetry.Metric
+		get  func(telemetry.Metric) map[string]interface{}
+	}{
+		{"Count", telemetry.Count{Attributes: map[string]interface{}{"env": "prod"}}, func(m telemetry.Metric) map[string]interface{} { return m.(telemetry.Count).Attributes }},
+		{"Gauge", telemetry.Gauge{Attributes: map[string]interface{}{"env": "prod"}}, func(m telemetry.Metric) map[string]interface{} { return m.(telemetry.Gauge).Attributes }},
+		{"Summary", telemetry.Summary{Attributes: map[string]interface{}{"env": "prod"}}, func(m telemetry.Metric) map[string]interface{} { return m.(telemetry.Summary).Attributes }},
+		{"Histogram", telemetry.Histogram{Attributes: map[string]interface{}{"env": "prod"}}, func(m telemetry.Metric) map[string]interface{} { return m.(telemetry.Histogram).Attributes }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			merged := withCommonAttributes(test.in, common)
+			attrs := test.get(merged)
+			if attrs["service.name"] != "checkout" {
+				t.Errorf("expected common attribute to be merged in, got %v", attrs)
+			}
+			if attrs["env"] != "prod" {
+				t.Errorf("expected the metric's own attribute to win over common, got %v", attrs)
+			}
+		})
+	}
+}
+
+func TestWithCommonAttributesNoCommon(t *testing.T) {
+	m := telemetry.Count{Attributes: map[string]interface{}{"env": "prod"}}
+	merged := withCommonAttributes(m, nil)
+	if merged.(telemetry.Count).Attributes["env"] != "prod" {
+		t.Error("expected metric to be returned unchanged when there is no common block")
+	}
+}
+
+func TestMergeAttributesCommonOnly(t *testing.T) {
+	common := map[string]interface{}{"service.name": "checkout"}
+	out := mergeAttributes(common, nil)
+	if out["service.name"] != "checkout" {
+		t.Errorf("expected common attributes passed through, got %v", out)
+	}
+}
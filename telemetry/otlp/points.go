@@ -0,0 +1,170 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package otlp
+
+import (
+	"math"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/telemetry"
+)
+
+func pointAttrs(attrs pcommon.Map) map[string]interface{} {
+	return attrsToMap(attrs)
+}
+
+func numberValue(dp pmetric.NumberDataPoint) float64 {
+	if dp.ValueType() == pmetric.NumberDataPointValueTypeInt {
+		return float64(dp.IntValue())
+	}
+	return dp.DoubleValue()
+}
+
+func toGauge(name string, dp pmetric.NumberDataPoint) telemetry.Metric {
+	return telemetry.Gauge{
+		Name:       name,
+		Attributes: pointAttrs(dp.Attributes()),
+		Value:      numberValue(dp),
+		Timestamp:  dp.Timestamp().AsTime(),
+	}
+}
+
+func toCount(name string, dp pmetric.NumberDataPoint) telemetry.Metric {
+	return telemetry.Count{
+		Name:       name,
+		Attributes: pointAttrs(dp.Attributes()),
+		Value:      numberValue(dp),
+		Timestamp:  dp.Timestamp().AsTime(),
+		Interval:   intervalOf(dp.StartTimestamp(), dp.Timestamp()),
+	}
+}
+
+func intervalOf(start, end pcommon.Timestamp) time.Duration {
+	if start == 0 || end <= start {
+		return 0
+	}
+	return end.AsTime().Sub(start.AsTime())
+}
+
+func convertNumberPoints(name string, dps pmetric.NumberDataPointSlice, to func(string, pmetric.NumberDataPoint) telemetry.Metric) []telemetry.Metric {
+	out := make([]telemetry.Metric, 0, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		out = append(out, to(name, dps.At(i)))
+	}
+	return out
+}
+
+func convertSummaryPoints(name string, dps pmetric.SummaryDataPointSlice) []telemetry.Metric {
+	out := make([]telemetry.Metric, 0, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		var min, max float64
+		qv := dp.QuantileValues()
+		if qv.Len() > 0 {
+			min = qv.At(0).Value()
+			max = qv.At(qv.Len() - 1).Value()
+		}
+		out = append(out, telemetry.Summary{
+			Name:       name,
+			Attributes: pointAttrs(dp.Attributes()),
+			Count:      float64(dp.Count()),
+			Sum:        dp.Sum(),
+			Min:        min,
+			Max:        max,
+			Timestamp:  dp.Timestamp().AsTime(),
+			Interval:   intervalOf(dp.StartTimestamp(), dp.Timestamp()),
+		})
+	}
+	return out
+}
+
+func convertHistogramPoints(name string, dps pmetric.HistogramDataPointSlice) []telemetry.Metric {
+	out := make([]telemetry.Metric, 0, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		bounds := dp.ExplicitBounds()
+		counts := dp.BucketCounts()
+		buckets := make([]telemetry.HistogramBucket, 0, counts.Len())
+		var cumulative uint64
+		for b := 0; b < counts.Len(); b++ {
+			cumulative += counts.At(b)
+			upper := boundAt(bounds, b)
+			buckets = append(buckets, telemetry.HistogramBucket{UpperBound: upper, Count: cumulative})
+		}
+		out = append(out, telemetry.Histogram{
+			Name:       name,
+			Attributes: pointAttrs(dp.Attributes()),
+			Count:      dp.Count(),
+			Sum:        dp.Sum(),
+			Buckets:    buckets,
+			Timestamp:  dp.Timestamp().AsTime(),
+			Interval:   intervalOf(dp.StartTimestamp(), dp.Timestamp()),
+		})
+	}
+	return out
+}
+
+// boundAt returns the upper bound for bucket index i, where the final
+// bucket (one more than len(bounds)) is +Inf.
+func boundAt(bounds pcommon.Float64Slice, i int) float64 {
+	if i >= bounds.Len() {
+		return math.Inf(1)
+	}
+	return bounds.At(i)
+}
+
+func convertExponentialHistogramPoints(name string, dps pmetric.ExponentialHistogramDataPointSlice) []telemetry.Metric {
+	out := make([]telemetry.Metric, 0, dps.Len())
+	for i := 0; i < dps.Len(); i++ {
+		dp := dps.At(i)
+		out = append(out, telemetry.Histogram{
+			Name:           name,
+			Attributes:     pointAttrs(dp.Attributes()),
+			Count:          dp.Count(),
+			Sum:            dp.Sum(),
+			Schema:         dp.Scale(),
+			ZeroCount:      dp.ZeroCount(),
+			ZeroThreshold:  dp.ZeroThreshold(),
+			PositiveSpans:  convertSpans(dp.Positive()),
+			NegativeSpans:  convertSpans(dp.Negative()),
+			PositiveDeltas: toDeltas(dp.Positive().BucketCounts().AsRaw()),
+			NegativeDeltas: toDeltas(dp.Negative().BucketCounts().AsRaw()),
+			Timestamp:      dp.Timestamp().AsTime(),
+			Interval:       intervalOf(dp.StartTimestamp(), dp.Timestamp()),
+		})
+	}
+	return out
+}
+
+func convertSpans(buckets pmetric.ExponentialHistogramDataPointBuckets) []telemetry.BucketSpan {
+	// OTLP's ExponentialHistogramDataPointBuckets stores a single implicit
+	// span starting at Offset with one bucket per BucketCounts entry; expand
+	// it to this SDK's explicit BucketSpan so multi-span native histograms
+	// (which OTLP does not currently produce) are representable too.
+	if buckets.BucketCounts().Len() == 0 {
+		return nil
+	}
+	return []telemetry.BucketSpan{{
+		Offset: buckets.Offset(),
+		Length: uint32(buckets.BucketCounts().Len()),
+	}}
+}
+
+// toDeltas converts OTLP's absolute per-bucket counts into this SDK's
+// delta-from-previous-bucket encoding.
+func toDeltas(counts []uint64) []int64 {
+	if len(counts) == 0 {
+		return nil
+	}
+	deltas := make([]int64, len(counts))
+	var prev int64
+	for i, c := range counts {
+		deltas[i] = int64(c) - prev
+		prev = int64(c)
+	}
+	return deltas
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package otlp
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/telemetry"
+)
+
+func TestTransformGauge(t *testing.T) {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("service.name", "checkout")
+	sm := rm.ScopeMetrics().AppendEmpty()
+	m := sm.Metrics().AppendEmpty()
+	m.SetName("queue_depth")
+	m.SetEmptyGauge().DataPoints().AppendEmpty().SetDoubleValue(12.5)
+
+	batches, err := Transform(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 {
+		t.Fatalf("expected 1 batch, got %d", len(batches))
+	}
+	if len(batches[0]) != 2 {
+		t.Fatalf("expected a common block plus the metric batch, got %d entries", len(batches[0]))
+	}
+	mb := batches[0][1].(*telemetry.MetricBatch)
+	if len(mb.Metrics) != 1 {
+		t.Fatalf("expected 1 metric, got %d", len(mb.Metrics))
+	}
+	g := mb.Metrics[0].(telemetry.Gauge)
+	if g.Name != "queue_depth" || g.Value != 12.5 {
+		t.Error("unexpected gauge", g)
+	}
+	if _, ok := g.Attributes["service.name"]; ok {
+		t.Error("expected resource attribute to be lifted into the common block, not repeated on the point", g.Attributes)
+	}
+}
+
+func TestTransformMonotonicSumDelta(t *testing.T) {
+	md := pmetric.NewMetrics()
+	sm := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	m := sm.Metrics().AppendEmpty()
+	m.SetName("requests_total")
+	sum := m.SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	sum.DataPoints().AppendEmpty().SetDoubleValue(5)
+
+	batches, err := Transform(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := batches[0][0].(*telemetry.MetricBatch)
+	c := mb.Metrics[0].(telemetry.Count)
+	if c.Value != 5 {
+		t.Error("unexpected count", c)
+	}
+}
+
+func TestTransformCumulativeSumWithoutAggregator(t *testing.T) {
+	md := pmetric.NewMetrics()
+	sm := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	m := sm.Metrics().AppendEmpty()
+	m.SetName("requests_total")
+	sum := m.SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	sum.DataPoints().AppendEmpty().SetDoubleValue(5)
+
+	// Without an Aggregator, cumulative points are forwarded as-is.
+	batches, err := Transform(md)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := batches[0][0].(*telemetry.MetricBatch)
+	if len(mb.Metrics) != 1 {
+		t.Fatal(mb.Metrics)
+	}
+}
+
+func TestAggregatorDropsFirstSampleThenEmitsDelta(t *testing.T) {
+	agg := NewAggregator()
+
+	md := pmetric.NewMetrics()
+	sm := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	m := sm.Metrics().AppendEmpty()
+	m.SetName("requests_total")
+	sum := m.SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	sum.DataPoints().AppendEmpty().SetDoubleValue(5)
+
+	batches, err := Transform(md, WithAggregator(agg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb := batches[0][0].(*telemetry.MetricBatch)
+	if len(mb.Metrics) != 0 {
+		t.Fatal("expected first cumulative sample to be dropped", mb.Metrics)
+	}
+
+	md2 := pmetric.NewMetrics()
+	sm2 := md2.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	m2 := sm2.Metrics().AppendEmpty()
+	m2.SetName("requests_total")
+	sum2 := m2.SetEmptySum()
+	sum2.SetIsMonotonic(true)
+	sum2.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	sum2.DataPoints().AppendEmpty().SetDoubleValue(8)
+
+	batches2, err := Transform(md2, WithAggregator(agg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mb2 := batches2[0][0].(*telemetry.MetricBatch)
+	if len(mb2.Metrics) != 1 {
+		t.Fatal(mb2.Metrics)
+	}
+	c := mb2.Metrics[0].(telemetry.Count)
+	if c.Value != 3 {
+		t.Errorf("expected delta of 3, got %v", c.Value)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package promscrape converts a Prometheus exposition payload (the
+// `text/plain; version=0.0.4` line format or the delimited
+// `application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily`
+// stream) into telemetry.Metric values.
+//
+// The API is intentionally modeled on the classic Prometheus
+// extraction.Processor interface: callers ask ProcessorForRequestHeader for
+// the Processor that matches a scrape response's Content-Type header, then
+// call ProcessSingle to stream MetricFamily values out of the body as
+// telemetry.Metric values.
+package promscrape
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"time"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/telemetry"
+)
+
+// Metric is implemented by every telemetry metric type that a Processor may
+// produce.
+type Metric = telemetry.Metric
+
+// Ingester receives each Metric decoded from a scrape payload. It is called
+// once per sample (once per bucket/quantile for histograms and summaries
+// that are expanded rather than translated directly).
+type Ingester func(Metric) error
+
+// ProcessOptions carries the information a Processor needs but that isn't
+// present in every exposition payload.
+type ProcessOptions struct {
+	// Timestamp is used for any MetricFamily/sample that does not carry its
+	// own timestamp. If zero, time.Now() is used.
+	Timestamp time.Time
+
+	// BaseLabels are merged onto every metric's Attributes alongside its
+	// own labels, e.g. to attach a scrape target's "instance"/"job" labels
+	// without requiring the exposition payload to repeat them on every
+	// line. A metric's own labels win if a key is present in both.
+	BaseLabels map[string]string
+
+	// ExpandHistogramBuckets causes classic (bucketed) histograms to be
+	// expanded into one Count per bucket, with a "le" attribute carrying the
+	// bucket's upper bound, matching the SDK's pre-Histogram-type behavior.
+	// When false, histograms are dropped.
+	ExpandHistogramBuckets bool
+
+	// ExpandSummaryQuantiles causes summary quantiles to be emitted as
+	// additional Gauge metrics with a "quantile" attribute, alongside the
+	// Summary produced from sum/count (and min/max, taken from the 0 and 1
+	// quantiles when the payload has them).
+	ExpandSummaryQuantiles bool
+}
+
+func (o ProcessOptions) timestamp() time.Time {
+	if o.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return o.Timestamp
+}
+
+// Processor turns a Prometheus exposition payload into Metric values,
+// delivering each one to an Ingester.
+type Processor interface {
+	// ProcessSingle reads a single exposition payload from r, translating
+	// each Prometheus sample it contains into a Metric and passing it to
+	// ingester. It returns the first error encountered, either while
+	// decoding r or from ingester itself.
+	ProcessSingle(r io.Reader, ingester Ingester, opts ProcessOptions) error
+}
+
+// ProcessorForRequestHeader returns the Processor appropriate for a scrape
+// response's Content-Type header value, mirroring
+// prometheus/common/expfmt.NewDecoder's format negotiation. It supports the
+// text/plain 0.0.4 exposition format (the default when contentType is empty)
+// and the delimited protobuf MetricFamily stream.
+func ProcessorForRequestHeader(contentType string) (Processor, error) {
+	if contentType == "" {
+		return textProcessor{}, nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("promscrape: invalid Content-Type %q: %w", contentType, err)
+	}
+
+	switch mediaType {
+	case "application/vnd.google.protobuf":
+		if params["proto"] != "io.prometheus.client.MetricFamily" {
+			return nil, fmt.Errorf("promscrape: unrecognized protobuf message type %q", params["proto"])
+		}
+		if params["encoding"] != "delimited" {
+			return nil, fmt.Errorf("promscrape: unrecognized protobuf encoding %q", params["encoding"])
+		}
+		return protobufProcessor{}, nil
+	case "text/plain":
+		return textProcessor{}, nil
+	default:
+		return nil, fmt.Errorf("promscrape: unrecognized Content-Type %q", mediaType)
+	}
+}
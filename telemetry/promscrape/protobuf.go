@@ -0,0 +1,121 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package promscrape
+
+import (
+	"fmt"
+	"io"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// protobufProcessor decodes the delimited
+// application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily
+// stream, as produced by clients scraped with
+// `Accept: application/vnd.google.protobuf`.
+type protobufProcessor struct{}
+
+func (protobufProcessor) ProcessSingle(r io.Reader, ingester Ingester, opts ProcessOptions) error {
+	decoder := expfmt.NewDecoder(r, expfmt.FmtProtoDelim)
+	for {
+		var pb dto.MetricFamily
+		if err := decoder.Decode(&pb); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("promscrape: decoding protobuf MetricFamily: %w", err)
+		}
+		fam, err := fromProto(&pb)
+		if err != nil {
+			return err
+		}
+		if err := translateFamily(fam, opts, ingester); err != nil {
+			return err
+		}
+	}
+}
+
+func protoLabels(pairs []*dto.LabelPair) map[string]string {
+	if len(pairs) == 0 {
+		return nil
+	}
+	labels := make(map[string]string, len(pairs))
+	for _, p := range pairs {
+		labels[p.GetName()] = p.GetValue()
+	}
+	return labels
+}
+
+func protoTimestamp(m *dto.Metric) int64 {
+	return m.GetTimestampMs()
+}
+
+func fromProto(pb *dto.MetricFamily) (metricFamily, error) {
+	fam := metricFamily{name: pb.GetName(), help: pb.GetHelp()}
+
+	switch pb.GetType() {
+	case dto.MetricType_COUNTER:
+		fam.mType = typeCounter
+		for _, m := range pb.GetMetric() {
+			fam.samples = append(fam.samples, sample{
+				labels:    protoLabels(m.GetLabel()),
+				value:     m.GetCounter().GetValue(),
+				timestamp: protoTimestamp(m),
+			})
+		}
+	case dto.MetricType_GAUGE:
+		fam.mType = typeGauge
+		for _, m := range pb.GetMetric() {
+			fam.samples = append(fam.samples, sample{
+				labels:    protoLabels(m.GetLabel()),
+				value:     m.GetGauge().GetValue(),
+				timestamp: protoTimestamp(m),
+			})
+		}
+	case dto.MetricType_UNTYPED:
+		fam.mType = typeUntyped
+		for _, m := range pb.GetMetric() {
+			fam.samples = append(fam.samples, sample{
+				labels:    protoLabels(m.GetLabel()),
+				value:     m.GetUntyped().GetValue(),
+				timestamp: protoTimestamp(m),
+			})
+		}
+	case dto.MetricType_SUMMARY:
+		fam.mType = typeSummary
+		for _, m := range pb.GetMetric() {
+			s := m.GetSummary()
+			ss := summarySample{
+				sample:    sample{labels: protoLabels(m.GetLabel()), timestamp: protoTimestamp(m)},
+				count:     s.GetSampleCount(),
+				sum:       s.GetSampleSum(),
+				quantiles: make(map[float64]float64, len(s.GetQuantile())),
+			}
+			for _, q := range s.GetQuantile() {
+				ss.quantiles[q.GetQuantile()] = q.GetValue()
+			}
+			fam.summary = append(fam.summary, ss)
+		}
+	case dto.MetricType_HISTOGRAM:
+		fam.mType = typeHistogram
+		for _, m := range pb.GetMetric() {
+			h := m.GetHistogram()
+			ss := summarySample{
+				sample:  sample{labels: protoLabels(m.GetLabel()), timestamp: protoTimestamp(m)},
+				count:   h.GetSampleCount(),
+				sum:     h.GetSampleSum(),
+				buckets: make(map[float64]uint64, len(h.GetBucket())),
+			}
+			for _, b := range h.GetBucket() {
+				ss.buckets[b.GetUpperBound()] = b.GetCumulativeCount()
+			}
+			fam.summary = append(fam.summary, ss)
+		}
+	default:
+		return metricFamily{}, fmt.Errorf("promscrape: unsupported metric type %v for family %q", pb.GetType(), pb.GetName())
+	}
+
+	return fam, nil
+}
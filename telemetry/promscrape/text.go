@@ -0,0 +1,323 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package promscrape
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// textProcessor decodes the Prometheus text/plain 0.0.4 exposition format.
+type textProcessor struct{}
+
+func (textProcessor) ProcessSingle(r io.Reader, ingester Ingester, opts ProcessOptions) error {
+	families, err := parseText(r)
+	if err != nil {
+		return err
+	}
+	for _, fam := range families {
+		if err := translateFamily(*fam, opts, ingester); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseText is a line-oriented parser for the text exposition format. It
+// understands HELP/TYPE comments, label sets, and the "le"/"quantile" label
+// convention used by histograms and summaries, but does not attempt to
+// validate the full exposition-format grammar.
+func parseText(r io.Reader) (map[string]*metricFamily, error) {
+	families := make(map[string]*metricFamily)
+	byName := func(name string) *metricFamily {
+		fam, ok := families[name]
+		if !ok {
+			fam = &metricFamily{name: name, mType: typeUntyped}
+			families[name] = fam
+		}
+		return fam
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			parseComment(line, byName)
+			continue
+		}
+		if err := parseSampleLine(line, families, byName); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("promscrape: reading exposition text: %w", err)
+	}
+	return families, nil
+}
+
+func parseComment(line string, byName func(string) *metricFamily) {
+	fields := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(line, "#")), " ", 3)
+	fields = trimEmpty(fields)
+	if len(fields) < 2 {
+		return
+	}
+	switch fields[0] {
+	case "HELP":
+		if len(fields) == 3 {
+			byName(fields[1]).help = fields[2]
+		}
+	case "TYPE":
+		if len(fields) == 3 {
+			fam := byName(fields[1])
+			switch fields[2] {
+			case "counter":
+				fam.mType = typeCounter
+			case "gauge":
+				fam.mType = typeGauge
+			case "summary":
+				fam.mType = typeSummary
+			case "histogram":
+				fam.mType = typeHistogram
+			default:
+				fam.mType = typeUntyped
+			}
+		}
+	}
+}
+
+func trimEmpty(fields []string) []string {
+	out := fields[:0]
+	for _, f := range fields {
+		if f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// parseSampleLine handles a single "name{labels} value [timestamp]" line.
+func parseSampleLine(line string, families map[string]*metricFamily, byName func(string) *metricFamily) error {
+	name, labels, rest, err := splitNameLabels(line)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return fmt.Errorf("promscrape: missing value in line %q", line)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return fmt.Errorf("promscrape: invalid value in line %q: %w", line, err)
+	}
+	var ts int64
+	if len(fields) > 1 {
+		ms, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return fmt.Errorf("promscrape: invalid timestamp in line %q: %w", line, err)
+		}
+		ts = int64(ms)
+	}
+
+	fam, suffix := componentFamily(families, name)
+	if fam == nil {
+		fam = byName(name)
+	}
+	s := sample{labels: labels, value: value, timestamp: ts}
+
+	switch suffix {
+	case "_bucket":
+		le, ok := labels["le"]
+		if !ok {
+			return fmt.Errorf("promscrape: histogram bucket missing le label: %q", line)
+		}
+		bound, err := strconv.ParseFloat(le, 64)
+		if err != nil {
+			return fmt.Errorf("promscrape: invalid le label %q: %w", le, err)
+		}
+		ss := findOrAppendSummarySample(fam, delete1(labels, "le"), ts)
+		if ss.buckets == nil {
+			ss.buckets = make(map[float64]uint64)
+		}
+		ss.buckets[bound] = uint64(value)
+	case "_sum":
+		ss := findOrAppendSummarySample(fam, labels, ts)
+		ss.sum = value
+	case "_count":
+		ss := findOrAppendSummarySample(fam, labels, ts)
+		ss.count = uint64(value)
+	default:
+		if fam.mType == typeSummary {
+			q, ok := labels["quantile"]
+			if !ok {
+				return fmt.Errorf("promscrape: summary sample missing quantile label: %q", line)
+			}
+			quantile, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				return fmt.Errorf("promscrape: invalid quantile label %q: %w", q, err)
+			}
+			ss := findOrAppendSummarySample(fam, delete1(labels, "quantile"), ts)
+			if ss.quantiles == nil {
+				ss.quantiles = make(map[float64]float64)
+			}
+			ss.quantiles[quantile] = value
+			break
+		}
+		fam.samples = append(fam.samples, s)
+	}
+	return nil
+}
+
+// componentFamily looks up the family that name's suffix-stripped base
+// would belong to, but only treats it as a histogram/summary component
+// (_bucket, _sum, _count) when that base family already exists and was
+// declared with the matching TYPE. Otherwise name is its own metric, even
+// if it happens to end in one of these suffixes (e.g. "errors_count").
+func componentFamily(families map[string]*metricFamily, name string) (*metricFamily, string) {
+	base, suffix := trimKnownSuffix(name)
+	if suffix == "" {
+		return nil, ""
+	}
+	fam, ok := families[base]
+	if !ok {
+		return nil, ""
+	}
+	switch suffix {
+	case "_bucket":
+		if fam.mType != typeHistogram {
+			return nil, ""
+		}
+	case "_sum", "_count":
+		if fam.mType != typeHistogram && fam.mType != typeSummary {
+			return nil, ""
+		}
+	}
+	return fam, suffix
+}
+
+// findOrAppendSummarySample returns the summarySample in fam matching
+// labels, creating one if necessary. Prometheus exposes the component lines
+// of a summary/histogram (quantiles, buckets, _sum, _count) as separate
+// samples sharing the remaining label set, so they must be reassembled.
+func findOrAppendSummarySample(fam *metricFamily, labels map[string]string, ts int64) *summarySample {
+	key := labelKey(labels)
+	for i := range fam.summary {
+		if labelKey(fam.summary[i].labels) == key {
+			return &fam.summary[i]
+		}
+	}
+	fam.summary = append(fam.summary, summarySample{sample: sample{labels: labels, timestamp: ts}})
+	return &fam.summary[len(fam.summary)-1]
+}
+
+func labelKey(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+func delete1(labels map[string]string, key string) map[string]string {
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if k != key {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func trimKnownSuffix(name string) (base, suffix string) {
+	for _, suf := range []string{"_bucket", "_sum", "_count"} {
+		if strings.HasSuffix(name, suf) {
+			return strings.TrimSuffix(name, suf), suf
+		}
+	}
+	return name, ""
+}
+
+func splitNameLabels(line string) (name string, labels map[string]string, rest string, err error) {
+	brace := strings.IndexByte(line, '{')
+	if brace == -1 {
+		sp := strings.IndexByte(line, ' ')
+		if sp == -1 {
+			return "", nil, "", fmt.Errorf("promscrape: malformed sample line %q", line)
+		}
+		return line[:sp], nil, line[sp+1:], nil
+	}
+	name = strings.TrimSpace(line[:brace])
+	end := strings.IndexByte(line[brace:], '}')
+	if end == -1 {
+		return "", nil, "", fmt.Errorf("promscrape: unterminated label set in line %q", line)
+	}
+	end += brace
+	labels, err = parseLabels(line[brace+1 : end])
+	if err != nil {
+		return "", nil, "", err
+	}
+	return name, labels, strings.TrimSpace(line[end+1:]), nil
+}
+
+func parseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return labels, nil
+	}
+	for _, part := range splitLabelPairs(s) {
+		eq := strings.IndexByte(part, '=')
+		if eq == -1 {
+			return nil, fmt.Errorf("promscrape: malformed label %q", part)
+		}
+		key := strings.TrimSpace(part[:eq])
+		val, err := strconv.Unquote(strings.TrimSpace(part[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("promscrape: malformed label value %q: %w", part, err)
+		}
+		labels[key] = val
+	}
+	return labels, nil
+}
+
+// splitLabelPairs splits a comma-separated label list while respecting
+// commas inside quoted label values.
+func splitLabelPairs(s string) []string {
+	var parts []string
+	var inQuotes bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			if i == 0 || s[i-1] != '\\' {
+				inQuotes = !inQuotes
+			}
+		case ',':
+			if !inQuotes {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, s[start:])
+	return parts
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package promscrape
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/telemetry"
+)
+
+func collect(t *testing.T, body string, opts ProcessOptions) []Metric {
+	t.Helper()
+	proc, err := ProcessorForRequestHeader("text/plain; version=0.0.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Metric
+	err = proc.ProcessSingle(strings.NewReader(body), func(m Metric) error {
+		got = append(got, m)
+		return nil
+	}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestProcessSingleCounter(t *testing.T) {
+	body := `# HELP http_requests_total total requests
+# TYPE http_requests_total counter
+http_requests_total{method="get",code="200"} 1027 1395066363000
+`
+	ts := time.Date(2014, time.November, 28, 1, 1, 0, 0, time.UTC)
+	metrics := collect(t, body, ProcessOptions{Timestamp: ts})
+	if len(metrics) != 1 {
+		t.Fatal(metrics)
+	}
+	c, ok := metrics[0].(telemetry.Count)
+	if !ok {
+		t.Fatalf("expected Count, got %T", metrics[0])
+	}
+	if c.Name != "http_requests_total" || c.Value != 1027 {
+		t.Error("unexpected counter", c)
+	}
+	if c.Attributes["method"] != "get" || c.Attributes["code"] != "200" {
+		t.Error("unexpected labels", c.Attributes)
+	}
+}
+
+func TestProcessSingleGaugeNoTimestamp(t *testing.T) {
+	body := `# TYPE temp_c gauge
+temp_c 23.5
+`
+	ts := time.Date(2014, time.November, 28, 1, 1, 0, 0, time.UTC)
+	metrics := collect(t, body, ProcessOptions{Timestamp: ts})
+	if len(metrics) != 1 {
+		t.Fatal(metrics)
+	}
+	g := metrics[0].(telemetry.Gauge)
+	if g.Value != 23.5 || !g.Timestamp.Equal(ts) {
+		t.Error("unexpected gauge", g)
+	}
+}
+
+func TestProcessSingleSummary(t *testing.T) {
+	body := `# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 0.05
+rpc_duration_seconds{quantile="0.9"} 0.1
+rpc_duration_seconds_sum 1.0
+rpc_duration_seconds_count 10
+`
+	metrics := collect(t, body, ProcessOptions{Timestamp: time.Now(), ExpandSummaryQuantiles: true})
+	var s telemetry.Summary
+	quantiles := 0
+	for _, m := range metrics {
+		switch v := m.(type) {
+		case telemetry.Summary:
+			s = v
+		case telemetry.Gauge:
+			quantiles++
+		}
+	}
+	if s.Sum != 1.0 || s.Count != 10 {
+		t.Error("unexpected summary", s)
+	}
+	if quantiles != 2 {
+		t.Error("expected 2 expanded quantiles, got", quantiles)
+	}
+}
+
+func TestProcessSingleHistogramExpansion(t *testing.T) {
+	body := `# TYPE request_size_bytes histogram
+request_size_bytes_bucket{le="100"} 5
+request_size_bytes_bucket{le="+Inf"} 8
+request_size_bytes_sum 640
+request_size_bytes_count 8
+`
+	metrics := collect(t, body, ProcessOptions{Timestamp: time.Now(), ExpandHistogramBuckets: true})
+	if len(metrics) != 2 {
+		t.Fatalf("expected 2 expanded bucket counts, got %d: %+v", len(metrics), metrics)
+	}
+	for _, m := range metrics {
+		c := m.(telemetry.Count)
+		if c.Name != "request_size_bytes_bucket" {
+			t.Error("unexpected bucket metric name", c.Name)
+		}
+	}
+}
+
+func TestProcessSingleHistogramDroppedByDefault(t *testing.T) {
+	body := `# TYPE request_size_bytes histogram
+request_size_bytes_bucket{le="+Inf"} 8
+request_size_bytes_sum 640
+request_size_bytes_count 8
+`
+	metrics := collect(t, body, ProcessOptions{Timestamp: time.Now()})
+	if len(metrics) != 0 {
+		t.Fatal(metrics)
+	}
+}
+
+func TestProcessSingleCounterNamedLikeHistogramSuffix(t *testing.T) {
+	body := `# TYPE errors_count counter
+errors_count 5
+`
+	metrics := collect(t, body, ProcessOptions{Timestamp: time.Now()})
+	if len(metrics) != 1 {
+		t.Fatal(metrics)
+	}
+	c, ok := metrics[0].(telemetry.Count)
+	if !ok {
+		t.Fatalf("expected Count, got %T", metrics[0])
+	}
+	if c.Name != "errors_count" || c.Value != 5 {
+		t.Error("unexpected counter", c)
+	}
+}
+
+func TestProcessSingleBaseLabels(t *testing.T) {
+	body := `# TYPE http_requests_total counter
+http_requests_total{code="200"} 1
+`
+	opts := ProcessOptions{
+		Timestamp:  time.Now(),
+		BaseLabels: map[string]string{"job": "api", "code": "base-should-lose"},
+	}
+	metrics := collect(t, body, opts)
+	if len(metrics) != 1 {
+		t.Fatal(metrics)
+	}
+	c, ok := metrics[0].(telemetry.Count)
+	if !ok {
+		t.Fatalf("expected Count, got %T", metrics[0])
+	}
+	if c.Attributes["job"] != "api" {
+		t.Error("expected BaseLabels to be merged onto the metric", c.Attributes)
+	}
+	if c.Attributes["code"] != "200" {
+		t.Error("expected the metric's own label to win over BaseLabels", c.Attributes)
+	}
+}
+
+func TestProcessorForRequestHeader(t *testing.T) {
+	if _, err := ProcessorForRequestHeader(""); err != nil {
+		t.Error(err)
+	}
+	if _, err := ProcessorForRequestHeader("text/plain; version=0.0.4"); err != nil {
+		t.Error(err)
+	}
+	if _, err := ProcessorForRequestHeader("application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited"); err != nil {
+		t.Error(err)
+	}
+	if _, err := ProcessorForRequestHeader("application/json"); err == nil {
+		t.Error("expected error for unrecognized content type")
+	}
+}
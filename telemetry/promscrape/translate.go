@@ -0,0 +1,163 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package promscrape
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/newrelic/newrelic-telemetry-sdk-go/telemetry"
+)
+
+// metricType mirrors io.prometheus.client.MetricType.
+type metricType int
+
+const (
+	typeCounter metricType = iota
+	typeGauge
+	typeSummary
+	typeUntyped
+	typeHistogram
+)
+
+// sample is a single labeled observation decoded from either exposition
+// format, prior to translation into a telemetry.Metric.
+type sample struct {
+	labels    map[string]string
+	value     float64
+	timestamp int64 // unix milliseconds; 0 means "use ProcessOptions.Timestamp"
+}
+
+// summarySample additionally carries the quantile/count/sum fields that only
+// apply to summary and histogram families.
+type summarySample struct {
+	sample
+	count     uint64
+	sum       float64
+	quantiles map[float64]float64 // summary only
+	buckets   map[float64]uint64  // histogram only: upper bound -> cumulative count
+}
+
+// metricFamily is the format-agnostic decode target for both the text and
+// protobuf processors; translateFamily turns it into telemetry.Metric
+// values.
+type metricFamily struct {
+	name    string
+	help    string
+	mType   metricType
+	samples []sample        // counter, gauge, untyped
+	summary []summarySample // summary, histogram
+}
+
+// mergeLabels flattens sets into a single map[string]interface{}, with
+// later sets overriding keys set by earlier ones. Passing opts.BaseLabels
+// first lets it double as the "apply these to every metric, but let the
+// metric's own labels win on conflict" merge translateFamily needs.
+func mergeLabels(sets ...map[string]string) map[string]interface{} {
+	size := 0
+	for _, s := range sets {
+		size += len(s)
+	}
+	out := make(map[string]interface{}, size)
+	for _, s := range sets {
+		for k, v := range s {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func sampleTimestamp(ts int64, opts ProcessOptions) int64 {
+	if ts != 0 {
+		return ts
+	}
+	return opts.timestamp().UnixNano() / int64(1e6)
+}
+
+func fromUnixMillis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC()
+}
+
+func translateFamily(fam metricFamily, opts ProcessOptions, ingest Ingester) error {
+	switch fam.mType {
+	case typeCounter:
+		for _, s := range fam.samples {
+			m := telemetry.Count{
+				Name:       fam.name,
+				Attributes: mergeLabels(opts.BaseLabels, s.labels),
+				Value:      s.value,
+				Timestamp:  fromUnixMillis(sampleTimestamp(s.timestamp, opts)),
+			}
+			if err := ingest(m); err != nil {
+				return err
+			}
+		}
+	case typeGauge, typeUntyped:
+		for _, s := range fam.samples {
+			m := telemetry.Gauge{
+				Name:       fam.name,
+				Attributes: mergeLabels(opts.BaseLabels, s.labels),
+				Value:      s.value,
+				Timestamp:  fromUnixMillis(sampleTimestamp(s.timestamp, opts)),
+			}
+			if err := ingest(m); err != nil {
+				return err
+			}
+		}
+	case typeSummary:
+		for _, s := range fam.summary {
+			ts := fromUnixMillis(sampleTimestamp(s.timestamp, opts))
+			m := telemetry.Summary{
+				Name:       fam.name,
+				Attributes: mergeLabels(opts.BaseLabels, s.labels),
+				Count:      float64(s.count),
+				Sum:        s.sum,
+				Timestamp:  ts,
+			}
+			if v, ok := s.quantiles[0]; ok {
+				m.Min = v
+			}
+			if v, ok := s.quantiles[1]; ok {
+				m.Max = v
+			}
+			if err := ingest(m); err != nil {
+				return err
+			}
+			if opts.ExpandSummaryQuantiles {
+				for q, v := range s.quantiles {
+					attrs := mergeLabels(opts.BaseLabels, map[string]string{"quantile": strconv.FormatFloat(q, 'g', -1, 64)}, s.labels)
+					g := telemetry.Gauge{
+						Name:       fam.name + "_quantile",
+						Attributes: attrs,
+						Value:      v,
+						Timestamp:  ts,
+					}
+					if err := ingest(g); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	case typeHistogram:
+		if !opts.ExpandHistogramBuckets {
+			return nil
+		}
+		for _, s := range fam.summary {
+			ts := fromUnixMillis(sampleTimestamp(s.timestamp, opts))
+			for le, count := range s.buckets {
+				attrs := mergeLabels(opts.BaseLabels, map[string]string{"le": strconv.FormatFloat(le, 'g', -1, 64)}, s.labels)
+				c := telemetry.Count{
+					Name:       fam.name + "_bucket",
+					Attributes: attrs,
+					Value:      float64(count),
+					Timestamp:  ts,
+				}
+				if err := ingest(c); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
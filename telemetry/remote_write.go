@@ -0,0 +1,421 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	writev2 "github.com/prometheus/prometheus/prompb/io/prometheus/write/v2"
+)
+
+// remoteWriteVersionHeader is echoed back on every response, matching the
+// header Prometheus remote_write agents send and expect acknowledged.
+const remoteWriteVersionHeader = "X-Prometheus-Remote-Write-Version"
+
+const labelMetricName = "__name__"
+
+// maxTrackedSeries bounds remoteWriteReceiver.lastValue so a source that
+// churns through series (e.g. labels with a high-cardinality value) can't
+// grow the cache without bound. Once the bound is hit the whole cache is
+// reset, so the next sample of every series is treated as a first sample
+// and dropped (no Count is recorded for it), rather than letting memory
+// grow without bound.
+const maxTrackedSeries = 100000
+
+// RemoteWriteHandler returns an http.Handler that accepts Prometheus
+// remote_write v1 (snappy-compressed, protobuf-encoded prompb.WriteRequest)
+// and v2 (io.prometheus.write.v2.Request) requests and records the
+// contained series on h as Metric values: counters (per metadata) become
+// Count deltas computed from a per-series last-value cache, gauges become
+// Gauge, and summaries/histograms are reassembled from their component
+// quantile/bucket/_sum/_count series into Summary/Histogram.
+//
+// It responds 202 on success and 400 if the request body cannot be
+// decoded; it does not itself enforce authentication - wrap the returned
+// handler if that's required.
+func RemoteWriteHandler(h *Harvester) http.Handler {
+	rw := &remoteWriteReceiver{harvester: h, lastValue: make(map[string]float64)}
+	return http.HandlerFunc(rw.ServeHTTP)
+}
+
+type remoteWriteReceiver struct {
+	harvester *Harvester
+
+	mu sync.Mutex
+	// lastValue caches the most recent sample per series fingerprint so
+	// counters (which Prometheus always reports as a cumulative total) can
+	// be converted into the delta this SDK's Count metric expects. Guarded
+	// by mu since ServeHTTP is invoked concurrently by net/http.
+	lastValue map[string]float64
+}
+
+func (rw *remoteWriteReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(remoteWriteVersionHeader, "0.1.0")
+
+	compressed, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("reading body: %s", err), http.StatusBadRequest)
+		return
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("snappy decode: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	var series []decodedSeries
+	if ct := r.Header.Get("Content-Type"); strings.Contains(ct, "io.prometheus.write.v2.Request") {
+		series, err = decodeV2(data)
+	} else {
+		series, err = decodeV1(data)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := rw.record(series); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// metricKind is a wire-format-agnostic view of a series' Prometheus metric
+// type, used to drive how its samples are turned into Metric values.
+type metricKind int
+
+const (
+	kindUnknown metricKind = iota
+	kindCounter
+	kindGauge
+	kindSummary
+	kindHistogram
+)
+
+// decodedSample is a single labeled observation, independent of whether it
+// came from a v1 prompb.WriteRequest or a v2 io.prometheus.write.v2.Request.
+type decodedSample struct {
+	value     float64
+	timestamp int64 // unix milliseconds
+}
+
+// decodedSeries is one series (name, attributes, declared type, and its
+// samples) decoded from either remote_write wire format.
+type decodedSeries struct {
+	name   string
+	attrs  map[string]interface{}
+	kind   metricKind
+	values []decodedSample
+}
+
+func decodeV1(data []byte) ([]decodedSeries, error) {
+	var req prompb.WriteRequest
+	if err := proto.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("protobuf decode: %w", err)
+	}
+
+	metadata := make(map[string]prompb.MetricMetadata_MetricType, len(req.Metadata))
+	for _, m := range req.Metadata {
+		metadata[m.MetricFamilyName] = m.Type
+	}
+
+	out := make([]decodedSeries, 0, len(req.Timeseries))
+	for _, ts := range req.Timeseries {
+		name, attrs := splitLabels(ts.Labels)
+		if name == "" {
+			return nil, fmt.Errorf("remote write series missing %s label", labelMetricName)
+		}
+		kind := v1Kind(metadata, name)
+		values := make([]decodedSample, len(ts.Samples))
+		for i, s := range ts.Samples {
+			values[i] = decodedSample{value: s.Value, timestamp: s.Timestamp}
+		}
+		out = append(out, decodedSeries{name: name, attrs: attrs, kind: kind, values: values})
+	}
+	return out, nil
+}
+
+// v1Kind looks metricType up by name first, then (for the component series
+// of a summary/histogram, e.g. "foo_bucket") by its suffix-stripped base
+// name, since v1 metadata is keyed by family name, not by component series
+// name.
+func v1Kind(metadata map[string]prompb.MetricMetadata_MetricType, name string) metricKind {
+	if t, ok := metadata[name]; ok {
+		return v1KindFromType(t)
+	}
+	base, suffix := trimComponentSuffix(name)
+	if suffix == "" {
+		return kindUnknown
+	}
+	if t, ok := metadata[base]; ok {
+		return v1KindFromType(t)
+	}
+	return kindUnknown
+}
+
+func v1KindFromType(t prompb.MetricMetadata_MetricType) metricKind {
+	switch t {
+	case prompb.MetricMetadata_COUNTER:
+		return kindCounter
+	case prompb.MetricMetadata_GAUGE:
+		return kindGauge
+	case prompb.MetricMetadata_SUMMARY:
+		return kindSummary
+	case prompb.MetricMetadata_HISTOGRAM, prompb.MetricMetadata_GAUGE_HISTOGRAM:
+		return kindHistogram
+	default:
+		return kindUnknown
+	}
+}
+
+// decodeV2 decodes the remote_write v2 wire format, which replaces
+// per-series labels with refs into a shared Symbols string table and moves
+// the metric type onto each TimeSeries' own Metadata rather than a
+// top-level metadata list.
+func decodeV2(data []byte) ([]decodedSeries, error) {
+	var req writev2.Request
+	if err := proto.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("protobuf decode: %w", err)
+	}
+
+	out := make([]decodedSeries, 0, len(req.Timeseries))
+	for _, ts := range req.Timeseries {
+		name, attrs, err := splitLabelRefs(req.Symbols, ts.LabelsRefs)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			return nil, fmt.Errorf("remote write series missing %s label", labelMetricName)
+		}
+		values := make([]decodedSample, len(ts.Samples))
+		for i, s := range ts.Samples {
+			values[i] = decodedSample{value: s.Value, timestamp: s.Timestamp}
+		}
+		out = append(out, decodedSeries{name: name, attrs: attrs, kind: v2Kind(ts.Metadata.Type), values: values})
+	}
+	return out, nil
+}
+
+func v2Kind(t writev2.Metadata_MetricType) metricKind {
+	switch t {
+	case writev2.Metadata_METRIC_TYPE_COUNTER:
+		return kindCounter
+	case writev2.Metadata_METRIC_TYPE_GAUGE:
+		return kindGauge
+	case writev2.Metadata_METRIC_TYPE_SUMMARY:
+		return kindSummary
+	case writev2.Metadata_METRIC_TYPE_HISTOGRAM, writev2.Metadata_METRIC_TYPE_GAUGEHISTOGRAM:
+		return kindHistogram
+	default:
+		return kindUnknown
+	}
+}
+
+func splitLabelRefs(symbols []string, refs []uint32) (name string, attrs map[string]interface{}, err error) {
+	if len(refs)%2 != 0 {
+		return "", nil, fmt.Errorf("remote write: odd number of label refs")
+	}
+	attrs = make(map[string]interface{}, len(refs)/2)
+	for i := 0; i < len(refs); i += 2 {
+		nameRef, valRef := refs[i], refs[i+1]
+		if int(nameRef) >= len(symbols) || int(valRef) >= len(symbols) {
+			return "", nil, fmt.Errorf("remote write: label ref out of range")
+		}
+		k, v := symbols[nameRef], symbols[valRef]
+		if k == labelMetricName {
+			name = v
+			continue
+		}
+		attrs[k] = v
+	}
+	return name, attrs, nil
+}
+
+// record turns decoded series into Metric values on rw.harvester: counters
+// are converted to deltas via the per-series lastValue cache, gauges are
+// forwarded as-is, and summary/histogram series are reassembled from their
+// component _sum/_count/_bucket/quantile series (each arrives as its own
+// decodedSeries, sharing every label except "le"/"quantile") before being
+// recorded as a single Summary/Histogram per observation.
+func (rw *remoteWriteReceiver) record(series []decodedSeries) error {
+	histograms := map[string]*Histogram{}
+	histogramOrder := make([]string, 0)
+	summaries := map[string]*Summary{}
+	summaryOrder := make([]string, 0)
+
+	for _, s := range series {
+		switch s.kind {
+		case kindCounter:
+			for _, v := range s.values {
+				rw.recordCounter(s.name, s.attrs, v)
+			}
+		case kindHistogram:
+			base, suffix := trimComponentSuffix(s.name)
+			for _, v := range s.values {
+				key := componentKey(base, s.attrs, v.timestamp, "le")
+				h, ok := histograms[key]
+				if !ok {
+					h = &Histogram{Name: base, Attributes: withoutLabel(s.attrs, "le"), Timestamp: fromUnixMillis(v.timestamp)}
+					histograms[key] = h
+					histogramOrder = append(histogramOrder, key)
+				}
+				switch suffix {
+				case "_bucket":
+					le, ok := s.attrs["le"].(string)
+					if !ok {
+						return fmt.Errorf("remote write: histogram bucket %q missing le label", s.name)
+					}
+					bound, err := parseFloatLabel(le)
+					if err != nil {
+						return fmt.Errorf("remote write: invalid le label %q: %w", le, err)
+					}
+					h.Buckets = append(h.Buckets, HistogramBucket{UpperBound: bound, Count: uint64(v.value)})
+				case "_sum":
+					h.Sum = v.value
+				case "_count":
+					h.Count = uint64(v.value)
+				}
+			}
+		case kindSummary:
+			base, suffix := trimComponentSuffix(s.name)
+			for _, v := range s.values {
+				key := componentKey(base, s.attrs, v.timestamp, "quantile")
+				sm, ok := summaries[key]
+				if !ok {
+					sm = &Summary{Name: base, Attributes: withoutLabel(s.attrs, "quantile"), Timestamp: fromUnixMillis(v.timestamp)}
+					summaries[key] = sm
+					summaryOrder = append(summaryOrder, key)
+				}
+				switch suffix {
+				case "_sum":
+					sm.Sum = v.value
+				case "_count":
+					sm.Count = v.value
+				default:
+					// A bare quantile sample (e.g. "foo{quantile=\"0.5\"}
+					// 1.2"): this SDK's Summary has no per-quantile field,
+					// so it only contributes to identifying the series;
+					// the _sum/_count component carries the recorded value.
+				}
+			}
+		default: // GAUGE, UNKNOWN
+			for _, v := range s.values {
+				rw.harvester.RecordMetric(Gauge{
+					Name:       s.name,
+					Attributes: s.attrs,
+					Value:      v.value,
+					Timestamp:  fromUnixMillis(v.timestamp),
+				})
+			}
+		}
+	}
+
+	for _, key := range histogramOrder {
+		h := histograms[key]
+		sort.Slice(h.Buckets, func(i, j int) bool { return h.Buckets[i].UpperBound < h.Buckets[j].UpperBound })
+		rw.harvester.RecordMetric(*h)
+	}
+	for _, key := range summaryOrder {
+		rw.harvester.RecordMetric(*summaries[key])
+	}
+	return nil
+}
+
+func (rw *remoteWriteReceiver) recordCounter(name string, attrs map[string]interface{}, v decodedSample) {
+	key := seriesKey(name, attrs)
+
+	rw.mu.Lock()
+	if len(rw.lastValue) >= maxTrackedSeries {
+		rw.lastValue = make(map[string]float64)
+	}
+	prior, ok := rw.lastValue[key]
+	rw.lastValue[key] = v.value
+	rw.mu.Unlock()
+
+	if !ok {
+		return // first sample of the series: no prior value to delta against
+	}
+	rw.harvester.RecordMetric(Count{
+		Name:       name,
+		Attributes: attrs,
+		Value:      v.value - prior,
+		Timestamp:  fromUnixMillis(v.timestamp),
+	})
+}
+
+// trimComponentSuffix splits a summary/histogram component series name
+// (e.g. "foo_bucket") into its family name ("foo") and the suffix that
+// identified it, or returns name unchanged with an empty suffix if it
+// doesn't end in a known component suffix.
+func trimComponentSuffix(name string) (base, suffix string) {
+	for _, suf := range []string{"_bucket", "_sum", "_count"} {
+		if strings.HasSuffix(name, suf) {
+			return strings.TrimSuffix(name, suf), suf
+		}
+	}
+	return name, ""
+}
+
+// componentKey identifies the observation a component series belongs to:
+// its family name, remaining labels (excluding excludeLabel, e.g. "le" or
+// "quantile"), and timestamp.
+func componentKey(base string, attrs map[string]interface{}, millis int64, excludeLabel string) string {
+	return seriesKey(base, withoutLabel(attrs, excludeLabel)) + fmt.Sprintf("\x1e%d", millis)
+}
+
+func withoutLabel(attrs map[string]interface{}, key string) map[string]interface{} {
+	out := make(map[string]interface{}, len(attrs))
+	for k, v := range attrs {
+		if k != key {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func parseFloatLabel(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+func splitLabels(labels []prompb.Label) (name string, attrs map[string]interface{}) {
+	attrs = make(map[string]interface{}, len(labels))
+	for _, l := range labels {
+		if l.Name == labelMetricName {
+			name = l.Value
+			continue
+		}
+		attrs[l.Name] = l.Value
+	}
+	return name, attrs
+}
+
+func fromUnixMillis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC()
+}
+
+func seriesKey(name string, attrs map[string]interface{}) string {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	key := name
+	for _, k := range keys {
+		key += fmt.Sprintf("\x1f%s=%v", k, attrs[k])
+	}
+	return key
+}
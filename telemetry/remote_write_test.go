@@ -0,0 +1,173 @@
+// Copyright 2019 New Relic Corporation. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	writev2 "github.com/prometheus/prometheus/prompb/io/prometheus/write/v2"
+)
+
+func postRemoteWrite(t *testing.T, h http.Handler, req *prompb.WriteRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed := snappy.Encode(nil, data)
+	r := httptest.NewRequest(http.MethodPost, "/v1/write", bytes.NewReader(compressed))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestRemoteWriteHandlerGauge(t *testing.T) {
+	harvester, _ := NewHarvester(ConfigAPIKey("key"))
+	handler := RemoteWriteHandler(harvester)
+
+	rec := postRemoteWrite(t, handler, &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{
+			{
+				Labels: []prompb.Label{
+					{Name: "__name__", Value: "temp_c"},
+					{Name: "host", Value: "a"},
+				},
+				Samples: []prompb.Sample{{Value: 21.5, Timestamp: 1417136460000}},
+			},
+		},
+	})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(remoteWriteVersionHeader); got == "" {
+		t.Error("expected version header to be set")
+	}
+}
+
+func TestRemoteWriteHandlerCounterDeltas(t *testing.T) {
+	harvester, _ := NewHarvester(ConfigAPIKey("key"))
+	handler := RemoteWriteHandler(harvester)
+
+	series := prompb.TimeSeries{
+		Labels: []prompb.Label{{Name: "__name__", Value: "requests_total"}},
+	}
+	metadata := []prompb.MetricMetadata{{MetricFamilyName: "requests_total", Type: prompb.MetricMetadata_COUNTER}}
+
+	series.Samples = []prompb.Sample{{Value: 5, Timestamp: 1}}
+	rec := postRemoteWrite(t, handler, &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{series}, Metadata: metadata})
+	if rec.Code != http.StatusAccepted {
+		t.Fatal(rec.Code, rec.Body.String())
+	}
+
+	series.Samples = []prompb.Sample{{Value: 9, Timestamp: 2}}
+	rec = postRemoteWrite(t, handler, &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{series}, Metadata: metadata})
+	if rec.Code != http.StatusAccepted {
+		t.Fatal(rec.Code, rec.Body.String())
+	}
+}
+
+func TestRemoteWriteHandlerHistogramComponents(t *testing.T) {
+	harvester, _ := NewHarvester(ConfigAPIKey("key"))
+	handler := RemoteWriteHandler(harvester)
+
+	metadata := []prompb.MetricMetadata{{MetricFamilyName: "request_size", Type: prompb.MetricMetadata_HISTOGRAM}}
+	rec := postRemoteWrite(t, handler, &prompb.WriteRequest{
+		Metadata: metadata,
+		Timeseries: []prompb.TimeSeries{
+			{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "request_size_bucket"}, {Name: "le", Value: "100"}},
+				Samples: []prompb.Sample{{Value: 5, Timestamp: 1}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "request_size_bucket"}, {Name: "le", Value: "+Inf"}},
+				Samples: []prompb.Sample{{Value: 8, Timestamp: 1}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "request_size_sum"}},
+				Samples: []prompb.Sample{{Value: 640, Timestamp: 1}},
+			},
+			{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "request_size_count"}},
+				Samples: []prompb.Sample{{Value: 8, Timestamp: 1}},
+			},
+		},
+	})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRemoteWriteHandlerV2Counter(t *testing.T) {
+	harvester, _ := NewHarvester(ConfigAPIKey("key"))
+	handler := RemoteWriteHandler(harvester)
+
+	post := func(value float64, ts int64) *httptest.ResponseRecorder {
+		req := &writev2.Request{
+			Symbols: []string{"", labelMetricName, "requests_total"},
+			Timeseries: []writev2.TimeSeries{
+				{
+					LabelsRefs: []uint32{1, 2},
+					Samples:    []writev2.Sample{{Value: value, Timestamp: ts}},
+					Metadata:   writev2.Metadata{Type: writev2.Metadata_METRIC_TYPE_COUNTER},
+				},
+			},
+		}
+		data, err := proto.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compressed := snappy.Encode(nil, data)
+		r := httptest.NewRequest(http.MethodPost, "/v2/write", bytes.NewReader(compressed))
+		r.Header.Set("Content-Type", "application/x-protobuf;proto=io.prometheus.write.v2.Request")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		return rec
+	}
+
+	if rec := post(5, 1); rec.Code != http.StatusAccepted {
+		t.Fatal(rec.Code, rec.Body.String())
+	}
+	if rec := post(9, 2); rec.Code != http.StatusAccepted {
+		t.Fatal(rec.Code, rec.Body.String())
+	}
+}
+
+func TestRemoteWriteHandlerConcurrentWrites(t *testing.T) {
+	harvester, _ := NewHarvester(ConfigAPIKey("key"))
+	handler := RemoteWriteHandler(harvester)
+	metadata := []prompb.MetricMetadata{{MetricFamilyName: "requests_total", Type: prompb.MetricMetadata_COUNTER}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			series := prompb.TimeSeries{
+				Labels:  []prompb.Label{{Name: "__name__", Value: "requests_total"}},
+				Samples: []prompb.Sample{{Value: float64(i), Timestamp: int64(i)}},
+			}
+			postRemoteWrite(t, handler, &prompb.WriteRequest{Timeseries: []prompb.TimeSeries{series}, Metadata: metadata})
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestRemoteWriteHandlerBadBody(t *testing.T) {
+	harvester, _ := NewHarvester(ConfigAPIKey("key"))
+	handler := RemoteWriteHandler(harvester)
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/write", bytes.NewReader([]byte("not snappy")))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}